@@ -0,0 +1,110 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"path"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	kmspb "cloud.google.com/go/kms/apiv1/kmspb"
+)
+
+// KMSSigner signs via a cloud KMS asymmetric-sign API, so CI systems can
+// sign packages without the private key ever being materialized on disk.
+// keyURI addresses the key by scheme:
+//
+//	gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1
+//	awskms://alias/melange-signing
+//	azurekms://myvault.vault.azure.net/keys/melange-signing/abcd1234
+//
+// Only the gcpkms scheme is implemented by this type. awskms and azurekms
+// are deliberately out of scope here and NewKMSSigner rejects them with an
+// explicit "not yet implemented" error rather than silently no-oping;
+// wiring in their respective SDKs is left to follow-up work, not bundled
+// into this change.
+type KMSSigner struct {
+	keyURI string
+	client *kms.KeyManagementClient
+}
+
+// NewKMSSigner opens a client for the cloud KMS key named by keyURI.
+func NewKMSSigner(keyURI string) (*KMSSigner, error) {
+	u, err := url.Parse(keyURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMS key uri: %w", err)
+	}
+
+	switch u.Scheme {
+	case "gcpkms":
+		client, err := kms.NewKeyManagementClient(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("unable to open GCP KMS client: %w", err)
+		}
+		return &KMSSigner{keyURI: keyURI, client: client}, nil
+	case "awskms", "azurekms":
+		return nil, fmt.Errorf("%s signing is not yet implemented", u.Scheme)
+	default:
+		return nil, fmt.Errorf("unsupported KMS scheme %q", u.Scheme)
+	}
+}
+
+func (s *KMSSigner) Name() string {
+	u, err := url.Parse(s.keyURI)
+	if err != nil {
+		return "kms"
+	}
+	return u.Scheme
+}
+
+func (s *KMSSigner) PublicKeyName() string {
+	u, err := url.Parse(s.keyURI)
+	if err != nil {
+		return "kms.pub"
+	}
+	return path.Base(u.Path) + ".pub"
+}
+
+// SignSHA1 signs digest via the configured cloud KMS key. Despite the name
+// inherited from the Signer interface, Cloud KMS's asymmetric-sign API has
+// no SHA-1 digest variant and does not accept SHA-1 digests at all (SHA-256
+// is its minimum supported strength), so this backend requires digest to
+// already be a SHA-256 digest; pair it with PackageFormat: v3, whose control
+// block is hashed with sha256, rather than v2's SHA-1 scheme.
+func (s *KMSSigner) SignSHA1(digest []byte) ([]byte, error) {
+	u, err := url.Parse(s.keyURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMS key uri: %w", err)
+	}
+
+	if len(digest) != sha256.Size {
+		return nil, fmt.Errorf("KMS signing requires a sha256 digest (got %d bytes); use PackageFormat: v3", len(digest))
+	}
+
+	resp, err := s.client.AsymmetricSign(context.Background(), &kmspb.AsymmetricSignRequest{
+		Name: u.Host + u.Path,
+		Digest: &kmspb.Digest{
+			Digest: &kmspb.Digest_Sha256{Sha256: digest},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("KMS sign request failed: %w", err)
+	}
+
+	return resp.Signature, nil
+}
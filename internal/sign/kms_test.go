@@ -0,0 +1,51 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import "testing"
+
+func TestNewKMSSignerRejectsUnimplementedSchemes(t *testing.T) {
+	for _, uri := range []string{
+		"awskms://alias/melange-signing",
+		"azurekms://myvault.vault.azure.net/keys/melange-signing/abcd1234",
+	} {
+		if _, err := NewKMSSigner(uri); err == nil {
+			t.Errorf("NewKMSSigner(%q) = nil error, want a not-yet-implemented error", uri)
+		}
+	}
+}
+
+func TestNewKMSSignerUnsupportedScheme(t *testing.T) {
+	if _, err := NewKMSSigner("ftp://example.com/key"); err == nil {
+		t.Error("NewKMSSigner with an unsupported scheme = nil error, want one")
+	}
+}
+
+func TestKMSSignerSignSHA1RejectsNonSHA256Digest(t *testing.T) {
+	s := &KMSSigner{keyURI: "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"}
+
+	if _, err := s.SignSHA1(make([]byte, 20)); err == nil {
+		t.Error("SignSHA1 with a sha1-sized digest = nil error, want one: Cloud KMS requires sha256")
+	}
+}
+
+func TestKMSSignerPublicKeyName(t *testing.T) {
+	s := &KMSSigner{keyURI: "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"}
+
+	want := "1.pub"
+	if got := s.PublicKeyName(); got != want {
+		t.Errorf("PublicKeyName() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import (
+	"crypto"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ThalesIgnite/crypto11"
+)
+
+// PKCS11Signer signs using an RSA key held on a PKCS#11 token (a hardware
+// HSM, or a software one like SoftHSM), so the private key material never
+// has to be materialized on disk.
+//
+// The URI is of the form:
+//
+//	pkcs11:token=<token>;object=<label>?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-value=1234
+type PKCS11Signer struct {
+	uri string
+	ctx *crypto11.Context
+	key crypto11.Signer
+}
+
+// NewPKCS11Signer opens the PKCS#11 module named in uri and looks up the
+// signing key it identifies.
+func NewPKCS11Signer(uri string) (*PKCS11Signer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pkcs11 uri: %w", err)
+	}
+
+	cfg, err := crypto11.ConfigFromPKCS11URI(u)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build pkcs11 config: %w", err)
+	}
+
+	ctx, err := crypto11.Configure(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open pkcs11 module: %w", err)
+	}
+
+	key, err := ctx.FindKeyPairFromPKCS11URI(u)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find pkcs11 key: %w", err)
+	}
+
+	return &PKCS11Signer{uri: uri, ctx: ctx, key: key}, nil
+}
+
+func (s *PKCS11Signer) Name() string { return "pkcs11" }
+
+func (s *PKCS11Signer) PublicKeyName() string {
+	if u, err := url.Parse(s.uri); err == nil {
+		// object= is a path attribute (before the '?'), separated by ';'.
+		for _, attr := range strings.Split(u.Opaque, ";") {
+			if strings.HasPrefix(attr, "object=") {
+				if label, err := url.PathUnescape(strings.TrimPrefix(attr, "object=")); err == nil && label != "" {
+					return label + ".pub"
+				}
+			}
+		}
+		if label := u.Query().Get("object"); label != "" {
+			return label + ".pub"
+		}
+	}
+	return "pkcs11.pub"
+}
+
+func (s *PKCS11Signer) SignSHA1(digest []byte) ([]byte, error) {
+	return s.key.Sign(nil, digest, crypto.SHA1)
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sign implements the apk-tools v2 signature scheme: an RSA
+// PKCS#1v1.5 signature over the sha1 digest of control.tar.gz, stored as
+// `.SIGN.RSA.<pubkey basename>.pub` in its own gzipped tarball that is
+// prepended to control.tar.gz and data.tar.gz.
+package sign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Signer produces apk-tools v2 signatures without requiring callers to know
+// where or how the private key material is held.
+type Signer interface {
+	// Name identifies the signer implementation, for logging.
+	Name() string
+
+	// SignSHA1 signs a sha1 digest (typically of control.tar.gz) and
+	// returns the raw RSA PKCS#1v1.5 signature bytes.
+	SignSHA1(digest []byte) ([]byte, error)
+
+	// PublicKeyName is the basename apk-tools expects the signature entry
+	// to be suffixed with, e.g. "melange.rsa.pub".
+	PublicKeyName() string
+}
+
+// NewSigner selects a Signer implementation by the URI scheme of
+// signingKey: a bare path or "file://" loads an on-disk RSA key,
+// "pkcs11:" addresses a PKCS#11 token, and "gcpkms://" addresses a GCP KMS
+// key. "awskms://" and "azurekms://" are passed to NewKMSSigner, which
+// returns an error for them: no AWS or Azure signer exists.
+func NewSigner(signingKey, passphrase string) (Signer, error) {
+	u, err := url.Parse(signingKey)
+	if err != nil || u.Scheme == "" {
+		return NewRSAFileSigner(signingKey, passphrase)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return NewRSAFileSigner(filepath.Join(u.Host, u.Path), passphrase)
+	case "pkcs11":
+		return NewPKCS11Signer(signingKey)
+	case "gcpkms", "awskms", "azurekms":
+		return NewKMSSigner(signingKey)
+	default:
+		return nil, fmt.Errorf("unsupported signing key scheme %q", u.Scheme)
+	}
+}
+
+// RSAFileSigner signs with an RSA private key read from disk, optionally
+// protected by a passphrase. It is the original, default signing backend.
+type RSAFileSigner struct {
+	keyPath string
+	key     *rsa.PrivateKey
+}
+
+// NewRSAFileSigner loads the RSA private key at keyPath, decrypting it with
+// passphrase if it is password-protected.
+func NewRSAFileSigner(keyPath, passphrase string) (*RSAFileSigner, error) {
+	key, err := loadRSAPrivateKey(keyPath, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &RSAFileSigner{keyPath: keyPath, key: key}, nil
+}
+
+func (s *RSAFileSigner) Name() string { return "file" }
+
+func (s *RSAFileSigner) PublicKeyName() string {
+	return filepath.Base(s.keyPath) + ".pub"
+}
+
+func (s *RSAFileSigner) SignSHA1(digest []byte) ([]byte, error) {
+	return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA1, digest)
+}
+
+// loadRSAPrivateKey reads and parses a PEM-encoded RSA private key,
+// decrypting it first if passphrase is non-empty.
+func loadRSAPrivateKey(keyPath, passphrase string) (*rsa.PrivateKey, error) {
+	pemData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("unable to decode signing key %s: not PEM-encoded", keyPath)
+	}
+
+	der := block.Bytes
+	if passphrase != "" && x509.IsEncryptedPEMBlock(block) { //nolint:staticcheck
+		der, err = x509.DecryptPEMBlock(block, []byte(passphrase)) //nolint:staticcheck
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt signing key %s: %w", keyPath, err)
+		}
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(der)
+	if err != nil {
+		keyAny, err2 := x509.ParsePKCS8PrivateKey(der)
+		if err2 != nil {
+			return nil, fmt.Errorf("unable to parse signing key %s: %w", keyPath, err)
+		}
+		rsaKey, ok := keyAny.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s is not an RSA key", keyPath)
+		}
+		return rsaKey, nil
+	}
+
+	return key, nil
+}
+
+// RSASignSHA1Digest signs digest with the RSA private key at keyPath,
+// decrypting it with passphrase if needed. Kept for callers that have not
+// yet migrated to the Signer interface.
+func RSASignSHA1Digest(digest []byte, keyPath, passphrase string) ([]byte, error) {
+	signer, err := NewRSAFileSigner(keyPath, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return signer.SignSHA1(digest)
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // nolint:gosec
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestRSAKey(t *testing.T) (path string, key *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	path = filepath.Join(t.TempDir(), "melange.rsa")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return path, key
+}
+
+func TestRSAFileSignerPublicKeyNameHasPubSuffix(t *testing.T) {
+	keyPath, _ := writeTestRSAKey(t)
+
+	signer, err := NewRSAFileSigner(keyPath, "")
+	if err != nil {
+		t.Fatalf("NewRSAFileSigner: %v", err)
+	}
+
+	want := filepath.Base(keyPath) + ".pub"
+	if got := signer.PublicKeyName(); got != want {
+		t.Errorf("PublicKeyName() = %q, want %q", got, want)
+	}
+}
+
+func TestRSAFileSignerSignSHA1Verifies(t *testing.T) {
+	keyPath, key := writeTestRSAKey(t)
+
+	signer, err := NewRSAFileSigner(keyPath, "")
+	if err != nil {
+		t.Fatalf("NewRSAFileSigner: %v", err)
+	}
+
+	digest := sha1.Sum([]byte("control.tar.gz contents")) // nolint:gosec
+	sig, err := signer.SignSHA1(digest[:])
+	if err != nil {
+		t.Fatalf("SignSHA1: %v", err)
+	}
+
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, digest[:], sig); err != nil {
+		t.Errorf("signature does not verify against the signing key: %v", err)
+	}
+}
+
+func TestNewSignerUnsupportedScheme(t *testing.T) {
+	if _, err := NewSigner("ftp://example.com/key", ""); err == nil {
+		t.Error("NewSigner with an unsupported scheme = nil error, want one")
+	}
+}
+
+func TestNewSignerBarePathUsesFileBackend(t *testing.T) {
+	keyPath, _ := writeTestRSAKey(t)
+
+	signer, err := NewSigner(keyPath, "")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	if signer.Name() != "file" {
+		t.Errorf("Name() = %q, want %q for a bare key path", signer.Name(), "file")
+	}
+}
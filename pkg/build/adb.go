@@ -0,0 +1,108 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file implements melange's encoding of the APKv3 control block: a
+// typed, self-describing binary tree (string/blob/int/array/object nodes),
+// in the same spirit as apk-tools' own ADB (Alpine Data Block) format. It
+// is melange's own implementation of that shape rather than a byte-exact
+// reproduction of apk-tools' adb.h schema IDs, which are not available to
+// this module; buildAdbPackageInfo documents that caveat where it matters.
+package build
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// adbType tags the payload that follows a block's length prefix.
+type adbType byte
+
+const (
+	adbTypeString adbType = iota + 1
+	adbTypeBlob
+	adbTypeUint
+	adbTypeArray
+	adbTypeObject
+)
+
+// adbPair is one key/value entry of an encoded adbObject.
+type adbPair struct {
+	Key   string
+	Value []byte
+}
+
+// adbBlock frames payload with its type tag and a little-endian length, so
+// a reader can skip or recurse into it without a schema.
+func adbBlock(t adbType, payload []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(t))
+
+	var lenField [4]byte
+	binary.LittleEndian.PutUint32(lenField[:], uint32(len(payload)))
+	buf.Write(lenField[:])
+
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func adbString(s string) []byte {
+	return adbBlock(adbTypeString, []byte(s))
+}
+
+func adbBlob(b []byte) []byte {
+	return adbBlock(adbTypeBlob, b)
+}
+
+func adbUint(v uint64) []byte {
+	var payload [8]byte
+	binary.LittleEndian.PutUint64(payload[:], v)
+	return adbBlock(adbTypeUint, payload[:])
+}
+
+// adbStringArray encodes each of items as an adbString and wraps them in an
+// adbArray.
+func adbStringArray(items []string) []byte {
+	encoded := make([][]byte, 0, len(items))
+	for _, item := range items {
+		encoded = append(encoded, adbString(item))
+	}
+	return adbArray(encoded...)
+}
+
+// adbArray encodes a count-prefixed sequence of already-encoded child
+// blocks.
+func adbArray(items ...[]byte) []byte {
+	var buf bytes.Buffer
+	var countField [4]byte
+	binary.LittleEndian.PutUint32(countField[:], uint32(len(items)))
+	buf.Write(countField[:])
+	for _, item := range items {
+		buf.Write(item)
+	}
+	return adbBlock(adbTypeArray, buf.Bytes())
+}
+
+// adbObject encodes a count-prefixed sequence of (adbString key, encoded
+// value) pairs.
+func adbObject(pairs ...adbPair) []byte {
+	var buf bytes.Buffer
+	var countField [4]byte
+	binary.LittleEndian.PutUint32(countField[:], uint32(len(pairs)))
+	buf.Write(countField[:])
+	for _, pair := range pairs {
+		buf.Write(adbString(pair.Key))
+		buf.Write(pair.Value)
+	}
+	return adbBlock(adbTypeObject, buf.Bytes())
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// decodeAdbBlock splits a block into its type tag, declared payload length
+// and remaining bytes after the payload, mirroring the framing adbBlock
+// writes, so tests can assert on it without a full reader implementation.
+func decodeAdbBlock(t *testing.T, block []byte) (typ adbType, payload, rest []byte) {
+	t.Helper()
+	if len(block) < 5 {
+		t.Fatalf("block too short to contain a type byte and length prefix: %d bytes", len(block))
+	}
+	typ = adbType(block[0])
+	length := binary.LittleEndian.Uint32(block[1:5])
+	if int(5+length) > len(block) {
+		t.Fatalf("declared payload length %d exceeds remaining block bytes %d", length, len(block)-5)
+	}
+	return typ, block[5 : 5+length], block[5+length:]
+}
+
+func TestAdbStringRoundTripsFraming(t *testing.T) {
+	block := adbString("hello")
+
+	typ, payload, rest := decodeAdbBlock(t, block)
+	if typ != adbTypeString {
+		t.Errorf("type = %d, want adbTypeString", typ)
+	}
+	if string(payload) != "hello" {
+		t.Errorf("payload = %q, want %q", payload, "hello")
+	}
+	if len(rest) != 0 {
+		t.Errorf("rest = %d trailing bytes, want none", len(rest))
+	}
+}
+
+func TestAdbBlobPreservesArbitraryBytes(t *testing.T) {
+	data := []byte{0x00, 0xff, 0x10, 0x00, 0x20}
+	block := adbBlob(data)
+
+	typ, payload, _ := decodeAdbBlock(t, block)
+	if typ != adbTypeBlob {
+		t.Errorf("type = %d, want adbTypeBlob", typ)
+	}
+	if string(payload) != string(data) {
+		t.Errorf("payload = %v, want %v", payload, data)
+	}
+}
+
+func TestAdbUintEncodesLittleEndian64(t *testing.T) {
+	block := adbUint(0x0102030405060708)
+
+	typ, payload, _ := decodeAdbBlock(t, block)
+	if typ != adbTypeUint {
+		t.Errorf("type = %d, want adbTypeUint", typ)
+	}
+	if got := binary.LittleEndian.Uint64(payload); got != 0x0102030405060708 {
+		t.Errorf("payload decodes to %#x, want %#x", got, uint64(0x0102030405060708))
+	}
+}
+
+func TestAdbArrayCountPrefixMatchesItemCount(t *testing.T) {
+	items := [][]byte{adbString("a"), adbString("b"), adbString("c")}
+	block := adbArray(items...)
+
+	typ, payload, _ := decodeAdbBlock(t, block)
+	if typ != adbTypeArray {
+		t.Errorf("type = %d, want adbTypeArray", typ)
+	}
+
+	count := binary.LittleEndian.Uint32(payload[:4])
+	if count != uint32(len(items)) {
+		t.Errorf("count prefix = %d, want %d", count, len(items))
+	}
+}
+
+func TestAdbObjectEncodesKeyValuePairs(t *testing.T) {
+	block := adbObject(
+		adbPair{Key: "name", Value: adbString("foo")},
+		adbPair{Key: "size", Value: adbUint(42)},
+	)
+
+	typ, payload, _ := decodeAdbBlock(t, block)
+	if typ != adbTypeObject {
+		t.Errorf("type = %d, want adbTypeObject", typ)
+	}
+
+	count := binary.LittleEndian.Uint32(payload[:4])
+	if count != 2 {
+		t.Fatalf("count prefix = %d, want 2", count)
+	}
+
+	rest := payload[4:]
+	keyTyp, keyPayload, rest := decodeAdbBlock(t, rest)
+	if keyTyp != adbTypeString || string(keyPayload) != "name" {
+		t.Errorf("first key = (%d, %q), want (adbTypeString, %q)", keyTyp, keyPayload, "name")
+	}
+	valTyp, valPayload, _ := decodeAdbBlock(t, rest)
+	if valTyp != adbTypeString || string(valPayload) != "foo" {
+		t.Errorf("first value = (%d, %q), want (adbTypeString, %q)", valTyp, valPayload, "foo")
+	}
+}
+
+func TestAdbStringArrayWrapsEachItem(t *testing.T) {
+	block := adbStringArray([]string{"x", "y"})
+
+	typ, payload, _ := decodeAdbBlock(t, block)
+	if typ != adbTypeArray {
+		t.Errorf("type = %d, want adbTypeArray", typ)
+	}
+	count := binary.LittleEndian.Uint32(payload[:4])
+	if count != 2 {
+		t.Errorf("count prefix = %d, want 2", count)
+	}
+}
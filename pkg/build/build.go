@@ -0,0 +1,103 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Option configures a Context being constructed by New.
+type Option func(*Context) error
+
+// New builds a Context by applying opts in order. ctx is threaded through
+// for future cancellation of long-running options (e.g. fetching a remote
+// config); none of the options below need it yet.
+//
+// If no WithWorkspaceDir option is given, WorkspaceDir defaults to OutDir,
+// matching the common case of building and packaging out of the same
+// directory.
+func New(_ context.Context, opts ...Option) (*Context, error) {
+	bc := &Context{}
+
+	for _, opt := range opts {
+		if err := opt(bc); err != nil {
+			return nil, err
+		}
+	}
+
+	if bc.WorkspaceDir == "" {
+		bc.WorkspaceDir = bc.OutDir
+	}
+
+	return bc, nil
+}
+
+// WithConfig loads the melange build Configuration from configFile. vars,
+// if non-empty, is expanded against the raw file with text/template before
+// it is parsed as YAML, so a config can reference them as e.g.
+// `{{.version}}`; pass nil to use the config as written.
+func WithConfig(configFile string, vars map[string]string) Option {
+	return func(bc *Context) error {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return fmt.Errorf("unable to read config %s: %w", configFile, err)
+		}
+
+		if len(vars) > 0 {
+			tmpl, err := template.New(filepath.Base(configFile)).Parse(string(data))
+			if err != nil {
+				return fmt.Errorf("unable to parse config %s as template: %w", configFile, err)
+			}
+
+			var expanded bytes.Buffer
+			if err := tmpl.Execute(&expanded, vars); err != nil {
+				return fmt.Errorf("unable to expand vars in config %s: %w", configFile, err)
+			}
+			data = expanded.Bytes()
+		}
+
+		var cfg Configuration
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("unable to parse config %s: %w", configFile, err)
+		}
+
+		bc.Configuration = cfg
+		return nil
+	}
+}
+
+// WithOutDir sets the directory EmitPackage writes packages under.
+func WithOutDir(dir string) Option {
+	return func(bc *Context) error {
+		bc.OutDir = dir
+		return nil
+	}
+}
+
+// WithWorkspaceDir sets the directory EmitPackage reads package contents
+// from. Defaults to OutDir if never set.
+func WithWorkspaceDir(dir string) Option {
+	return func(bc *Context) error {
+		bc.WorkspaceDir = dir
+		return nil
+	}
+}
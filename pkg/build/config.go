@@ -0,0 +1,99 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"time"
+
+	apkotypes "chainguard.dev/apko/pkg/build/types"
+)
+
+// Configuration is the parsed melange build file: the package being built
+// plus everything needed to build it.
+type Configuration struct {
+	Package Package `yaml:"package"`
+}
+
+// Copyright records one license declaration for a Package.
+type Copyright struct {
+	License string `yaml:"license"`
+}
+
+// Dependencies holds the manually-declared dependency edges for a Package,
+// which EmitPackage folds together with the so:/cmd: virtuals it infers.
+type Dependencies struct {
+	Runtime []string `yaml:"runtime,omitempty"`
+}
+
+// Package describes the apk melange produces: its identity, metadata, and
+// the knobs controlling how EmitPackage packages it.
+type Package struct {
+	Name         string       `yaml:"name"`
+	Version      string       `yaml:"version"`
+	Epoch        uint64       `yaml:"epoch"`
+	Description  string       `yaml:"description,omitempty"`
+	Copyright    []Copyright  `yaml:"copyright,omitempty"`
+	Dependencies Dependencies `yaml:"dependencies,omitempty"`
+
+	// Scripts holds the apk-tools install/upgrade/deinstall hook and
+	// trigger scripts to package into control.tar.gz. See scripts.go.
+	Scripts Scripts `yaml:"scripts,omitempty"`
+
+	// Options overrides the so:/cmd: virtuals EmitPackage infers from the
+	// workspace. See virtuals.go.
+	Options PackageOption `yaml:"options,omitempty"`
+}
+
+// Subpackage describes one additional apk produced alongside the main
+// Package from the same build.
+type Subpackage struct {
+	Name string `yaml:"name"`
+}
+
+// PipelineContext carries the state threaded through a single pipeline run,
+// including the Context it was configured with.
+type PipelineContext struct {
+	Context *Context
+}
+
+// Context holds everything about a single melange invocation: the parsed
+// Configuration, the workspace it builds in, and the knobs controlling how
+// EmitPackage packages the result.
+type Context struct {
+	Configuration Configuration
+
+	WorkspaceDir string
+	OutDir       string
+	Arch         apkotypes.Architecture
+
+	SigningKey        string
+	SigningPassphrase string
+
+	// SourceDateEpoch is the reproducible-build timestamp stamped into
+	// data, control and signature tarballs.
+	SourceDateEpoch time.Time
+
+	// BuildDate overrides SourceDateEpoch as the reproducible-build
+	// timestamp when set. See resolvedSourceDateEpoch in reproducible.go.
+	BuildDate time.Time
+
+	// PackageFormat selects the v2 or v3 apk container layout EmitPackage
+	// writes. See package_v3.go.
+	PackageFormat PackageFormat
+
+	// SBOMFormats names the SBOM documents EmitPackage generates for each
+	// package, defaulting to CycloneDX if empty. See sbom.go.
+	SBOMFormats []string
+}
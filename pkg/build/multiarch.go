@@ -0,0 +1,96 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	apkotypes "chainguard.dev/apko/pkg/build/types"
+)
+
+// BuildPackage runs the emit stage for a single architecture: it packages
+// ctx.Configuration.Package out of ctx.WorkspaceDir into
+// ctx.OutDir/ctx.Arch.ToAPK(). No build pipeline runs; the workspace is
+// packaged as it stands. BuildAllArchs is the multi-arch driver built on top
+// of this per-arch one.
+func (ctx *Context) BuildPackage() error {
+	return ctx.Configuration.Package.Emit(&PipelineContext{Context: ctx})
+}
+
+// BuildAllArchs fans a single melange build out across each of archs,
+// running BuildPackage once per architecture against an isolated workspace
+// (WorkspaceDir/arch-<arch>), and then indexing each arch's output directory so the
+// result is directly usable as an apk repository.
+//
+// Each arch's packages land in packages/<arch>/, as EmitPackage already
+// derives OutDir from Context.Arch.ToAPK().
+func (ctx *Context) BuildAllArchs(archs []apkotypes.Architecture) error {
+	for _, arch := range archs {
+		archCtx := *ctx
+		archCtx.Arch = arch
+		archCtx.WorkspaceDir = filepath.Join(ctx.WorkspaceDir, "arch-"+arch.ToAPK())
+
+		if err := archCtx.BuildPackage(); err != nil {
+			return fmt.Errorf("unable to build for arch %s: %w", arch.ToAPK(), err)
+		}
+
+		archDir := filepath.Join(ctx.OutDir, arch.ToAPK())
+		if err := indexArchDir(archDir); err != nil {
+			return fmt.Errorf("unable to index %s: %w", archDir, err)
+		}
+	}
+
+	return nil
+}
+
+// indexArchDir runs `apk index` over dir's .apk files so it is directly
+// usable as an apk repository, matching what `abuild` produces per arch.
+func indexArchDir(dir string) error {
+	args, err := apkIndexArgs(dir)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("apk", args...)
+	cmd.Dir = dir
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("apk index failed: %w: %s", err, out)
+	}
+
+	return nil
+}
+
+// apkIndexArgs expands dir's *.apk files in Go (exec.Command never invokes
+// a shell, so it can't glob for itself) and builds the `apk index` argv
+// that indexes them by explicit name.
+func apkIndexArgs(dir string) ([]string, error) {
+	apks, err := filepath.Glob(filepath.Join(dir, "*.apk"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list apks in %s: %w", dir, err)
+	}
+	if len(apks) == 0 {
+		return nil, fmt.Errorf("no apks found in %s", dir)
+	}
+
+	names := make([]string, len(apks))
+	for i, apk := range apks {
+		names[i] = filepath.Base(apk)
+	}
+
+	return append([]string{"index", "--output", "APKINDEX.tar.gz", "--rewrite-arch", filepath.Base(dir)}, names...), nil
+}
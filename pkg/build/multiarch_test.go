@@ -0,0 +1,66 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApkIndexArgsExpandsGlobToExplicitNames(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "x86_64")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo-1.0-r0.apk", "bar-2.0-r1.apk"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("apk"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "APKINDEX.tar.gz"), []byte("not an apk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := apkIndexArgs(dir)
+	if err != nil {
+		t.Fatalf("apkIndexArgs: %v", err)
+	}
+
+	want := map[string]bool{"foo-1.0-r0.apk": true, "bar-2.0-r1.apk": true}
+	var gotApks int
+	for _, arg := range args {
+		if want[arg] {
+			gotApks++
+		}
+		if arg == "*.apk" {
+			t.Fatalf("args = %v, want the glob expanded, not passed through literally", args)
+		}
+	}
+	if gotApks != len(want) {
+		t.Errorf("args = %v, want exactly the two staged apk filenames", args)
+	}
+	if args[0] != "index" || args[3] != "--rewrite-arch" || args[4] != "x86_64" {
+		t.Errorf("args = %v, want index/--output/--rewrite-arch flags preserved", args)
+	}
+}
+
+func TestApkIndexArgsNoApksIsAnError(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := apkIndexArgs(dir); err == nil {
+		t.Error("apkIndexArgs on an empty dir = nil error, want one")
+	}
+}
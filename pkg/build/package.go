@@ -25,6 +25,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
 
 	apkofs "chainguard.dev/apko/pkg/fs"
@@ -41,6 +42,33 @@ type PackageContext struct {
 	DataHash      string
 	OutDir        string
 	Logger        *log.Logger
+
+	scriptEntries []scriptEntry
+	virtuals      soVirtuals
+}
+
+// Virtuals returns the so:/cmd: provides and so: depends inferred from
+// scanning the workspace's ELF objects.
+func (pc *PackageContext) Virtuals() soVirtuals {
+	return pc.virtuals
+}
+
+// ScriptEntries returns the name+sha1 of each hook/trigger script staged
+// into control.tar.gz, for rendering into .PKGINFO.
+func (pc *PackageContext) ScriptEntries() []scriptEntry {
+	return pc.scriptEntries
+}
+
+// TriggerPaths returns the `triggers = ` path globs declared for this
+// package, for rendering into .PKGINFO.
+func (pc *PackageContext) TriggerPaths() []string {
+	return pc.triggerPaths()
+}
+
+// TriggerPathsLine joins TriggerPaths the way apk-tools expects them
+// encoded in .PKGINFO's single `triggers = ` line: one value, `:`-joined.
+func (pc *PackageContext) TriggerPathsLine() string {
+	return strings.Join(pc.triggerPaths(), ":")
 }
 
 func (pkg *Package) Emit(ctx *PipelineContext) error {
@@ -77,7 +105,7 @@ var controlTemplate = `
 # Generated by melange.
 pkgname = {{.PackageName}}
 pkgver = {{.Origin.Version}}-r{{.Origin.Epoch}}
-arch = x86_64
+arch = {{.Context.Arch.ToAPK}}
 size = {{.InstalledSize}}
 pkgdesc = {{.Origin.Description}}
 {{- range $copyright := .Origin.Copyright }}
@@ -86,6 +114,18 @@ license = {{ $copyright.License }}
 {{- range $dep := .Origin.Dependencies.Runtime }}
 depend = {{ $dep }}
 {{- end }}
+{{- range $dep := .Virtuals.Depends }}
+depend = {{ $dep }}
+{{- end }}
+{{- range $provide := .Virtuals.Provides }}
+provides = {{ $provide }}
+{{- end }}
+{{- if .TriggerPaths }}
+triggers = {{ .TriggerPathsLine }}
+{{- end }}
+{{- range $script := .ScriptEntries }}
+scripts = {{ $script.Name }}:{{ $script.SHA1 }}
+{{- end }}
 datahash = {{.DataHash}}
 `
 
@@ -94,8 +134,10 @@ func (pc *PackageContext) GenerateControlData(w io.Writer) error {
 	return template.Must(tmpl.Parse(controlTemplate)).Execute(w, pc)
 }
 
-func (pc *PackageContext) SignatureName() string {
-	return fmt.Sprintf(".SIGN.RSA.%s.pub", filepath.Base(pc.Context.SigningKey))
+// SignatureName returns the control.tar.gz entry name apk-tools expects the
+// signature to be stored under.
+func (pc *PackageContext) SignatureName(signer sign.Signer) string {
+	return fmt.Sprintf(".SIGN.RSA.%s", signer.PublicKeyName())
 }
 
 func combine(out io.Writer, inputs ...io.Reader) error {
@@ -108,25 +150,26 @@ func combine(out io.Writer, inputs ...io.Reader) error {
 	return nil
 }
 
-// TODO(kaniini): generate APKv3 packages
-func (pc *PackageContext) EmitPackage() error {
-	pc.Logger.Printf("generating package %s", pc.Identity())
-
+// buildDataTarball walks the package's workspace subdirectory, scans it for
+// so:/cmd: virtuals, and writes it out as a gzipped tarball to a temporary
+// file positioned at the start. The caller owns the returned file and must
+// close it.
+func (pc *PackageContext) buildDataTarball() (*os.File, fs.FS, error) {
 	dataTarGz, err := os.CreateTemp("", "melange-data-*.tar.gz")
 	if err != nil {
-		return fmt.Errorf("unable to open temporary file for writing: %w", err)
+		return nil, nil, fmt.Errorf("unable to open temporary file for writing: %w", err)
 	}
-	defer dataTarGz.Close()
 
 	tarctx, err := tarball.NewContext(
-		tarball.WithSourceDateEpoch(pc.Context.SourceDateEpoch),
+		tarball.WithSourceDateEpoch(pc.Context.resolvedSourceDateEpoch()),
 		tarball.WithOverrideUIDGID(0, 0),
 		tarball.WithOverrideUname("root"),
 		tarball.WithOverrideGname("root"),
 		tarball.WithUseChecksums(true),
 	)
 	if err != nil {
-		return fmt.Errorf("unable to build tarball context: %w", err)
+		dataTarGz.Close()
+		return nil, nil, fmt.Errorf("unable to build tarball context: %w", err)
 	}
 
 	fsys := apkofs.DirFS(pc.WorkspaceSubdir())
@@ -143,15 +186,32 @@ func (pc *PackageContext) EmitPackage() error {
 		pc.InstalledSize += fi.Size()
 		return nil
 	}); err != nil {
-		return fmt.Errorf("unable to preprocess package data: %w", err)
+		dataTarGz.Close()
+		return nil, nil, fmt.Errorf("unable to preprocess package data: %w", err)
+	}
+
+	virtuals, err := pc.scanELFVirtuals(fsys)
+	if err != nil {
+		dataTarGz.Close()
+		return nil, nil, fmt.Errorf("unable to scan for so:/cmd: virtuals: %w", err)
+	}
+	pc.virtuals = virtuals
+
+	files, err := walkFileTree(fsys)
+	if err != nil {
+		dataTarGz.Close()
+		return nil, nil, fmt.Errorf("unable to walk package files: %w", err)
+	}
+	if err := pc.writeSBOMs(files); err != nil {
+		dataTarGz.Close()
+		return nil, nil, fmt.Errorf("unable to generate SBOM: %w", err)
 	}
 
-	// TODO(kaniini): generate so:/cmd: virtuals for the filesystem
-	// prepare data.tar.gz
 	dataDigest := sha256.New()
 	dataMW := io.MultiWriter(dataDigest, dataTarGz)
 	if err := tarctx.WriteArchive(dataMW, fsys); err != nil {
-		return fmt.Errorf("unable to write data tarball: %w", err)
+		dataTarGz.Close()
+		return nil, nil, fmt.Errorf("unable to write data tarball: %w", err)
 	}
 
 	pc.DataHash = hex.EncodeToString(dataDigest.Sum(nil))
@@ -159,12 +219,35 @@ func (pc *PackageContext) EmitPackage() error {
 	pc.Logger.Printf("  data.tar.gz digest: %s", pc.DataHash)
 
 	if _, err := dataTarGz.Seek(0, io.SeekStart); err != nil {
-		return fmt.Errorf("unable to rewind data tarball: %w", err)
+		dataTarGz.Close()
+		return nil, nil, fmt.Errorf("unable to rewind data tarball: %w", err)
+	}
+
+	return dataTarGz, fsys, nil
+}
+
+// EmitPackage writes pc to OutDir in the container format selected by
+// pc.Context.PackageFormat, defaulting to the v2 (concatenated gzip
+// streams) format for backward compatibility.
+func (pc *PackageContext) EmitPackage() error {
+	if pc.Context.PackageFormat == FormatV3 {
+		return pc.EmitPackageV3()
+	}
+	return pc.emitPackageV2()
+}
+
+func (pc *PackageContext) emitPackageV2() error {
+	pc.Logger.Printf("generating package %s", pc.Identity())
+
+	dataTarGz, _, err := pc.buildDataTarball()
+	if err != nil {
+		return err
 	}
+	defer dataTarGz.Close()
 
 	// prepare control.tar.gz
 	multitarctx, err := tarball.NewContext(
-		tarball.WithSourceDateEpoch(pc.Context.SourceDateEpoch),
+		tarball.WithSourceDateEpoch(pc.Context.resolvedSourceDateEpoch()),
 		tarball.WithOverrideUIDGID(0, 0),
 		tarball.WithOverrideUname("root"),
 		tarball.WithOverrideGname("root"),
@@ -174,12 +257,26 @@ func (pc *PackageContext) EmitPackage() error {
 		return fmt.Errorf("unable to build tarball context: %w", err)
 	}
 
+	// tarball.Context.WriteArchive walks controlFS with fs.WalkDir.
+	// fs.WalkDir only sorts entries itself when the FS does not implement
+	// fs.ReadDirFS; memfs does, so control.tar.gz's archive order depends
+	// on memfs.FS.ReadDir returning entries in sorted order, which
+	// TestMemFSReadDirIsSorted in package_test.go pins down for the memfs version this
+	// module uses. pc.writeScripts also stages scripts in sorted name
+	// order itself rather than depending on this for its own ordering.
+	controlFS := memfs.New()
+
+	scriptEntries, err := pc.writeScripts(controlFS)
+	if err != nil {
+		return fmt.Errorf("unable to stage scripts: %w", err)
+	}
+	pc.scriptEntries = scriptEntries
+
 	var controlBuf bytes.Buffer
 	if err := pc.GenerateControlData(&controlBuf); err != nil {
 		return fmt.Errorf("unable to process control template: %w", err)
 	}
 
-	controlFS := memfs.New()
 	if err := controlFS.WriteFile(".PKGINFO", controlBuf.Bytes(), 0644); err != nil {
 		return fmt.Errorf("unable to build control FS: %w", err)
 	}
@@ -206,14 +303,18 @@ func (pc *PackageContext) EmitPackage() error {
 	combinedParts := []io.Reader{controlTarGz, dataTarGz}
 
 	if pc.Context.SigningKey != "" {
+		signer, err := sign.NewSigner(pc.Context.SigningKey, pc.Context.SigningPassphrase)
+		if err != nil {
+			return fmt.Errorf("unable to configure signer: %w", err)
+		}
+
 		signatureFS := memfs.New()
-		signatureBuf, err := sign.RSASignSHA1Digest(controlDigest.Sum(nil),
-			pc.Context.SigningKey, pc.Context.SigningPassphrase)
+		signatureBuf, err := signer.SignSHA1(controlDigest.Sum(nil))
 		if err != nil {
 			return fmt.Errorf("unable to generate signature: %w", err)
 		}
 
-		if err := signatureFS.WriteFile(pc.SignatureName(), signatureBuf, 0644); err != nil {
+		if err := signatureFS.WriteFile(pc.SignatureName(signer), signatureBuf, 0644); err != nil {
 			return fmt.Errorf("unable to build signature FS: %w", err)
 		}
 
@@ -0,0 +1,56 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"io/fs"
+	"sort"
+	"testing"
+
+	"github.com/psanford/memfs"
+)
+
+// TestMemFSReadDirIsSorted pins down the assumption emitPackageV2 relies on
+// for a deterministic control.tar.gz: memfs.FS implements fs.ReadDirFS, so
+// fs.WalkDir defers to memfs's own ReadDir instead of sorting for it. If
+// this ever regresses upstream, control.tar.gz's archive order would start
+// depending on write order instead of name order, breaking reproducible
+// builds for any package whose scripts don't already self-sort.
+func TestMemFSReadDirIsSorted(t *testing.T) {
+	fsys := memfs.New()
+	unsorted := []string{".trigger", ".PKGINFO", ".pre-install", ".post-install"}
+	for _, name := range unsorted {
+		if err := fsys.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatalf("WriteFile(%s): %v", name, err)
+		}
+	}
+
+	var visited []string
+	if err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			visited = append(visited, path)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("WalkDir: %v", err)
+	}
+
+	if !sort.StringsAreSorted(visited) {
+		t.Fatalf("fs.WalkDir visited %v, want lexically sorted order — memfs.FS.ReadDir no longer returns sorted entries", visited)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// resolvedSourceDateEpoch returns the timestamp melange stamps into data,
+// control and signature tarballs. BuildDate takes precedence over the
+// legacy SourceDateEpoch field, which in turn takes precedence over the
+// $SOURCE_DATE_EPOCH environment variable, so a bare
+// `SOURCE_DATE_EPOCH=1672531200 melange build` reproduces byte-identical
+// output without any config changes.
+func (ctx *Context) resolvedSourceDateEpoch() time.Time {
+	if !ctx.BuildDate.IsZero() {
+		return ctx.BuildDate
+	}
+	if !ctx.SourceDateEpoch.IsZero() {
+		return ctx.SourceDateEpoch
+	}
+	if v := os.Getenv("SOURCE_DATE_EPOCH"); v != "" {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC()
+		}
+	}
+	return time.Time{}
+}
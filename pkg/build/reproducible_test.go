@@ -0,0 +1,56 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolvedSourceDateEpochPrecedence(t *testing.T) {
+	buildDate := time.Unix(3000, 0).UTC()
+	sourceDateEpoch := time.Unix(2000, 0).UTC()
+
+	t.Run("BuildDate wins over SourceDateEpoch", func(t *testing.T) {
+		ctx := &Context{BuildDate: buildDate, SourceDateEpoch: sourceDateEpoch}
+		if got := ctx.resolvedSourceDateEpoch(); !got.Equal(buildDate) {
+			t.Errorf("resolvedSourceDateEpoch() = %v, want BuildDate %v", got, buildDate)
+		}
+	})
+
+	t.Run("SourceDateEpoch wins over env when BuildDate unset", func(t *testing.T) {
+		t.Setenv("SOURCE_DATE_EPOCH", "1000")
+		ctx := &Context{SourceDateEpoch: sourceDateEpoch}
+		if got := ctx.resolvedSourceDateEpoch(); !got.Equal(sourceDateEpoch) {
+			t.Errorf("resolvedSourceDateEpoch() = %v, want SourceDateEpoch %v", got, sourceDateEpoch)
+		}
+	})
+
+	t.Run("falls back to SOURCE_DATE_EPOCH env", func(t *testing.T) {
+		t.Setenv("SOURCE_DATE_EPOCH", "1000")
+		ctx := &Context{}
+		want := time.Unix(1000, 0).UTC()
+		if got := ctx.resolvedSourceDateEpoch(); !got.Equal(want) {
+			t.Errorf("resolvedSourceDateEpoch() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("zero value when nothing is set", func(t *testing.T) {
+		ctx := &Context{}
+		if got := ctx.resolvedSourceDateEpoch(); !got.IsZero() {
+			t.Errorf("resolvedSourceDateEpoch() = %v, want zero time", got)
+		}
+	})
+}
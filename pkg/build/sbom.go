@@ -0,0 +1,233 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sbomFormatCycloneDX and sbomFormatSPDX are the values accepted in
+// Context.SBOMFormats.
+const (
+	sbomFormatCycloneDX = "cyclonedx"
+	sbomFormatSPDX      = "spdx"
+)
+
+// cdxComponent is a minimal CycloneDX component entry: just enough to
+// describe the package itself and the files it installs.
+type cdxComponent struct {
+	Type     string       `json:"type"`
+	Name     string       `json:"name"`
+	Version  string       `json:"version,omitempty"`
+	Hashes   []cdxHash    `json:"hashes,omitempty"`
+	Licenses []cdxLicense `json:"licenses,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicense struct {
+	License cdxLicenseID `json:"license"`
+}
+
+type cdxLicenseID struct {
+	ID string `json:"id"`
+}
+
+// cdxDocument is a minimal CycloneDX 1.4 BOM document.
+type cdxDocument struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+	Dependencies []string       `json:"dependencies,omitempty"`
+}
+
+type cdxMetadata struct {
+	Component cdxComponent `json:"component"`
+}
+
+// spdxDocument is a minimal SPDX 2.3 document, enough to record package
+// identity, license and file digests.
+type spdxDocument struct {
+	SPDXVersion    string        `json:"spdxVersion"`
+	DataLicense    string        `json:"dataLicense"`
+	SPDXID         string        `json:"SPDXID"`
+	Name           string        `json:"name"`
+	PackageLicense string        `json:"packageLicense,omitempty"`
+	Packages       []spdxPackage `json:"packages"`
+}
+
+type spdxPackage struct {
+	SPDXID           string     `json:"SPDXID"`
+	Name             string     `json:"name"`
+	VersionInfo      string     `json:"versionInfo"`
+	LicenseConcluded string     `json:"licenseConcluded,omitempty"`
+	Files            []spdxFile `json:"files"`
+}
+
+type spdxFile struct {
+	FileName  string         `json:"fileName"`
+	Checksums []spdxChecksum `json:"checksums"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+// sbomPath returns the well-known path, relative to the package root, SBOMs
+// are shipped at inside data.tar.gz, for the given format's file extension.
+func (pc *PackageContext) sbomPath(ext string) string {
+	return fmt.Sprintf("var/lib/db/sbom/%s.%s.json", pc.Identity(), ext)
+}
+
+// generateCycloneDX builds a CycloneDX BOM describing pc: its identity,
+// license, resolved runtime dependencies (including so:/cmd: virtuals), and
+// every regular file with its sha256. File sizes are not recorded.
+func (pc *PackageContext) generateCycloneDX(files []fileEntry) ([]byte, error) {
+	licenses := make([]cdxLicense, 0, len(pc.Origin.Copyright))
+	for _, c := range pc.Origin.Copyright {
+		licenses = append(licenses, cdxLicense{License: cdxLicenseID{ID: c.License}})
+	}
+
+	components := make([]cdxComponent, 0, len(files))
+	for _, f := range files {
+		components = append(components, cdxComponent{
+			Type: "file",
+			Name: f.Path,
+			Hashes: []cdxHash{
+				{Alg: "SHA-256", Content: f.SHA256},
+			},
+		})
+	}
+
+	deps := append([]string{}, pc.Origin.Dependencies.Runtime...)
+	deps = append(deps, pc.virtuals.Depends...)
+	deps = append(deps, pc.virtuals.Provides...)
+
+	doc := cdxDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Component: cdxComponent{
+				Type:     "application",
+				Name:     pc.PackageName,
+				Version:  fmt.Sprintf("%s-r%d", pc.Origin.Version, pc.Origin.Epoch),
+				Licenses: licenses,
+			},
+		},
+		Components:   components,
+		Dependencies: deps,
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// generateSPDX builds an SPDX document describing pc, analogous to
+// generateCycloneDX.
+func (pc *PackageContext) generateSPDX(files []fileEntry) ([]byte, error) {
+	spdxFiles := make([]spdxFile, 0, len(files))
+	for _, f := range files {
+		spdxFiles = append(spdxFiles, spdxFile{
+			FileName: f.Path,
+			Checksums: []spdxChecksum{
+				{Algorithm: "SHA256", ChecksumValue: f.SHA256},
+			},
+		})
+	}
+
+	var license string
+	if len(pc.Origin.Copyright) > 0 {
+		license = pc.Origin.Copyright[0].License
+	}
+
+	doc := spdxDocument{
+		SPDXVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        pc.Identity(),
+		Packages: []spdxPackage{
+			{
+				SPDXID:           "SPDXRef-Package-" + pc.PackageName,
+				Name:             pc.PackageName,
+				VersionInfo:      fmt.Sprintf("%s-r%d", pc.Origin.Version, pc.Origin.Epoch),
+				LicenseConcluded: license,
+				Files:            spdxFiles,
+			},
+		},
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// writeSBOMs generates an SBOM in every format named in
+// pc.Context.SBOMFormats (CycloneDX by default) describing files, and
+// materializes each one at its well-known path inside the workspace so it
+// is picked up by the data.tar.gz walk like any other installed file, plus
+// a sibling copy next to the .apk in OutDir.
+func (pc *PackageContext) writeSBOMs(files []fileEntry) error {
+	formats := pc.Context.SBOMFormats
+	if len(formats) == 0 {
+		formats = []string{sbomFormatCycloneDX}
+	}
+
+	for _, format := range formats {
+		var ext string
+		var sbom []byte
+		var err error
+
+		switch format {
+		case sbomFormatCycloneDX:
+			ext = "cdx"
+			sbom, err = pc.generateCycloneDX(files)
+		case sbomFormatSPDX:
+			ext = "spdx"
+			sbom, err = pc.generateSPDX(files)
+		default:
+			return fmt.Errorf("unsupported SBOM format %q", format)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to generate %s SBOM: %w", format, err)
+		}
+
+		inPkgPath := filepath.Join(pc.WorkspaceSubdir(), pc.sbomPath(ext))
+		if err := os.MkdirAll(filepath.Dir(inPkgPath), 0755); err != nil {
+			return fmt.Errorf("unable to stage %s SBOM: %w", format, err)
+		}
+		if err := os.WriteFile(inPkgPath, sbom, 0644); err != nil {
+			return fmt.Errorf("unable to stage %s SBOM: %w", format, err)
+		}
+		pc.InstalledSize += int64(len(sbom))
+
+		if err := os.MkdirAll(pc.OutDir, 0755); err != nil {
+			return fmt.Errorf("unable to create output directory: %w", err)
+		}
+
+		sidecarPath := fmt.Sprintf("%s/%s.%s.json", pc.OutDir, pc.Identity(), ext)
+		if err := os.WriteFile(sidecarPath, sbom, 0644); err != nil {
+			return fmt.Errorf("unable to write %s SBOM sidecar: %w", format, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func testSBOMPackageContext() *PackageContext {
+	return &PackageContext{
+		PackageName: "foo",
+		Origin: &Package{
+			Version:   "1.0",
+			Epoch:     0,
+			Copyright: []Copyright{{License: "Apache-2.0"}},
+		},
+	}
+}
+
+func TestGenerateCycloneDXIncludesFilesAndLicense(t *testing.T) {
+	pc := testSBOMPackageContext()
+	files := []fileEntry{
+		{Path: "usr/bin/foo", SHA256: "abc123", Size: 42, Mode: 0755},
+	}
+
+	data, err := pc.generateCycloneDX(files)
+	if err != nil {
+		t.Fatalf("generateCycloneDX: %v", err)
+	}
+
+	var doc cdxDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("generated CycloneDX doc is not valid JSON: %v", err)
+	}
+
+	if doc.BOMFormat != "CycloneDX" {
+		t.Errorf("BOMFormat = %q, want %q", doc.BOMFormat, "CycloneDX")
+	}
+	if doc.Metadata.Component.Name != "foo" {
+		t.Errorf("metadata component name = %q, want %q", doc.Metadata.Component.Name, "foo")
+	}
+	if len(doc.Metadata.Component.Licenses) != 1 || doc.Metadata.Component.Licenses[0].License.ID != "Apache-2.0" {
+		t.Errorf("metadata component licenses = %+v, want [Apache-2.0]", doc.Metadata.Component.Licenses)
+	}
+	if len(doc.Components) != 1 || doc.Components[0].Name != "usr/bin/foo" {
+		t.Errorf("components = %+v, want a single entry for usr/bin/foo", doc.Components)
+	}
+	if doc.Components[0].Hashes[0].Content != "abc123" {
+		t.Errorf("component hash = %q, want %q", doc.Components[0].Hashes[0].Content, "abc123")
+	}
+}
+
+func TestGenerateSPDXIncludesFilesAndLicense(t *testing.T) {
+	pc := testSBOMPackageContext()
+	files := []fileEntry{
+		{Path: "usr/bin/foo", SHA256: "abc123", Size: 42, Mode: 0755},
+	}
+
+	data, err := pc.generateSPDX(files)
+	if err != nil {
+		t.Fatalf("generateSPDX: %v", err)
+	}
+
+	var doc spdxDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("generated SPDX doc is not valid JSON: %v", err)
+	}
+
+	if doc.SPDXVersion != "SPDX-2.3" {
+		t.Errorf("SPDXVersion = %q, want %q", doc.SPDXVersion, "SPDX-2.3")
+	}
+	if len(doc.Packages) != 1 || doc.Packages[0].Name != "foo" {
+		t.Fatalf("packages = %+v, want a single entry named foo", doc.Packages)
+	}
+	if doc.Packages[0].LicenseConcluded != "Apache-2.0" {
+		t.Errorf("LicenseConcluded = %q, want %q", doc.Packages[0].LicenseConcluded, "Apache-2.0")
+	}
+	if len(doc.Packages[0].Files) != 1 || doc.Packages[0].Files[0].FileName != "usr/bin/foo" {
+		t.Errorf("files = %+v, want a single entry for usr/bin/foo", doc.Packages[0].Files)
+	}
+}
+
+func TestSBOMPathUsesFormatExtension(t *testing.T) {
+	pc := testSBOMPackageContext()
+
+	got := pc.sbomPath("cdx")
+	want := "var/lib/db/sbom/foo-1.0-r0.cdx.json"
+	if got != want {
+		t.Errorf("sbomPath(%q) = %q, want %q", "cdx", got, want)
+	}
+}
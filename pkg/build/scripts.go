@@ -0,0 +1,167 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"crypto/sha1" // nolint:gosec
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/psanford/memfs"
+)
+
+// Trigger describes a `.trigger` script which apk-tools invokes whenever a
+// file matching one of Paths is installed, upgraded or removed.
+type Trigger struct {
+	// Paths is the set of path globs which arm this trigger, written to
+	// .PKGINFO as a `triggers = ` line.
+	Paths []string `yaml:"paths,omitempty"`
+
+	// Script is either the literal contents of the trigger script, or a
+	// path (relative to the workspace) to a file containing it.
+	Script string `yaml:"script,omitempty"`
+}
+
+// Scripts holds the apk-tools install/upgrade/deinstall hook scripts for a
+// Package. Subpackage has no Scripts field; Subpackage.Emit packages the
+// main Package's Scripts. Each field may either contain the literal script
+// contents or a path, relative to the workspace directory, of a file to
+// read the script from.
+type Scripts struct {
+	Trigger Trigger `yaml:"trigger,omitempty"`
+
+	PreInstall    string `yaml:"pre-install,omitempty"`
+	PostInstall   string `yaml:"post-install,omitempty"`
+	PreUpgrade    string `yaml:"pre-upgrade,omitempty"`
+	PostUpgrade   string `yaml:"post-upgrade,omitempty"`
+	PreDeinstall  string `yaml:"pre-deinstall,omitempty"`
+	PostDeinstall string `yaml:"post-deinstall,omitempty"`
+}
+
+// scriptEntry is a single named script staged into control.tar.gz, along
+// with the sha1 digest apk-tools verifies it against.
+type scriptEntry struct {
+	Name string
+	SHA1 string
+}
+
+// scriptSpecs returns the control-filename -> configured script value pairs
+// declared on this package, skipping any that are unset.
+func (pc *PackageContext) scriptSpecs() map[string]string {
+	s := pc.Origin.Scripts
+
+	specs := map[string]string{
+		".pre-install":    s.PreInstall,
+		".post-install":   s.PostInstall,
+		".pre-upgrade":    s.PreUpgrade,
+		".post-upgrade":   s.PostUpgrade,
+		".pre-deinstall":  s.PreDeinstall,
+		".post-deinstall": s.PostDeinstall,
+	}
+
+	if s.Trigger.Script != "" {
+		specs[".trigger"] = s.Trigger.Script
+	}
+
+	for name, contents := range specs {
+		if contents == "" {
+			delete(specs, name)
+		}
+	}
+
+	return specs
+}
+
+// resolveScript returns the contents of a script, reading it from the
+// workspace if it names a file there rather than containing the script
+// inline.
+func (pc *PackageContext) resolveScript(contents string) ([]byte, error) {
+	candidate := filepath.Join(pc.Context.WorkspaceDir, contents)
+	if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+		return os.ReadFile(candidate)
+	}
+
+	return []byte(contents), nil
+}
+
+// resolveScripts reads the configured hook and trigger scripts, returning
+// both the scriptEntry list (name + sha1) to render into .PKGINFO and the
+// raw script bytes, keyed by control-filename, so callers can embed them in
+// whichever container format they are writing.
+func (pc *PackageContext) resolveScripts() ([]scriptEntry, map[string][]byte, error) {
+	specs := pc.scriptSpecs()
+	if len(specs) == 0 {
+		return nil, nil, nil
+	}
+
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]scriptEntry, 0, len(specs))
+	contents := make(map[string][]byte, len(specs))
+	for _, name := range names {
+		data, err := pc.resolveScript(specs[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to resolve script %s: %w", name, err)
+		}
+
+		contents[name] = data
+
+		digest := sha1.Sum(data) // nolint:gosec
+		entries = append(entries, scriptEntry{
+			Name: name,
+			SHA1: hex.EncodeToString(digest[:]),
+		})
+	}
+
+	return entries, contents, nil
+}
+
+// writeScripts resolves the configured hook and trigger scripts and stages
+// them into controlFS in sorted name order, returning the scriptEntry list
+// (name + sha1) to render into .PKGINFO so apk-tools can validate them.
+//
+// It iterates entries (already sorted by resolveScripts) rather than the
+// contents map directly, since Go map iteration order is unspecified and
+// control.tar.gz's write order must not depend on it.
+func (pc *PackageContext) writeScripts(controlFS *memfs.FS) ([]scriptEntry, error) {
+	entries, contents, err := pc.resolveScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if err := controlFS.WriteFile(entry.Name, contents[entry.Name], 0755); err != nil {
+			return nil, fmt.Errorf("unable to write script %s to control FS: %w", entry.Name, err)
+		}
+	}
+
+	return entries, nil
+}
+
+// triggerPaths returns the `triggers = ` path globs declared for this
+// package, or nil if no trigger script was configured.
+func (pc *PackageContext) triggerPaths() []string {
+	if pc.Origin.Scripts.Trigger.Script == "" {
+		return nil
+	}
+	return pc.Origin.Scripts.Trigger.Paths
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/psanford/memfs"
+)
+
+func testPackageContext(t *testing.T) *PackageContext {
+	t.Helper()
+	return &PackageContext{
+		Context: &Context{WorkspaceDir: t.TempDir()},
+		Origin: &Package{
+			Scripts: Scripts{
+				PreInstall:  "echo pre-install",
+				PostInstall: "echo post-install",
+				Trigger: Trigger{
+					Script: "echo trigger",
+					Paths:  []string{"/usr/lib/libfoo.so.*", "/etc/foo/*"},
+				},
+			},
+		},
+	}
+}
+
+func TestWriteScriptsOrderIsDeterministic(t *testing.T) {
+	pc := testPackageContext(t)
+	controlFS := memfs.New()
+
+	entries, err := pc.writeScripts(controlFS)
+	if err != nil {
+		t.Fatalf("writeScripts: %v", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("entries = %v, want scriptEntry names in sorted order", names)
+	}
+
+	for _, e := range entries {
+		data, err := controlFS.ReadFile(e.Name)
+		if err != nil {
+			t.Errorf("controlFS missing staged script %s: %v", e.Name, err)
+			continue
+		}
+		if len(data) == 0 {
+			t.Errorf("controlFS script %s was staged empty", e.Name)
+		}
+	}
+}
+
+func TestWriteScriptsNoneConfigured(t *testing.T) {
+	pc := &PackageContext{Context: &Context{WorkspaceDir: t.TempDir()}, Origin: &Package{}}
+	controlFS := memfs.New()
+
+	entries, err := pc.writeScripts(controlFS)
+	if err != nil {
+		t.Fatalf("writeScripts: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("entries = %v, want none when no scripts are configured", entries)
+	}
+}
+
+func TestTriggerPathsLineJoinsWithColon(t *testing.T) {
+	pc := testPackageContext(t)
+
+	got := pc.TriggerPathsLine()
+	want := "/usr/lib/libfoo.so.*:/etc/foo/*"
+	if got != want {
+		t.Errorf("TriggerPathsLine() = %q, want %q", got, want)
+	}
+}
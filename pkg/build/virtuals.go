@@ -0,0 +1,192 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"debug/elf"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// PackageOption holds knobs a Package can set to override the so:/cmd:
+// virtuals melange would otherwise infer from the workspace. Subpackage has
+// no Options field; Subpackage.Emit applies the main Package's Options.
+type PackageOption struct {
+	// NoProvides suppresses all inferred so:/cmd: provides.
+	NoProvides bool `yaml:"no-provides,omitempty"`
+
+	// NoDepends suppresses all inferred so: depends.
+	NoDepends bool `yaml:"no-depends,omitempty"`
+
+	// NoCommands suppresses inferred cmd: provides, while leaving so:
+	// provides and depends intact.
+	NoCommands bool `yaml:"no-commands,omitempty"`
+
+	// PinnedVirtuals overrides the version emitted for a specific so:
+	// provides, keyed by soname (e.g. "libfoo.so.1").
+	PinnedVirtuals map[string]string `yaml:"pinned-virtuals,omitempty"`
+}
+
+// binDirs are the paths under which an installed executable earns a
+// `cmd:<name>` provides virtual.
+var binDirs = map[string]bool{
+	"bin":      true,
+	"sbin":     true,
+	"usr/bin":  true,
+	"usr/sbin": true,
+}
+
+// soVirtuals holds the so:/cmd: provides and so: depends discovered by
+// scanning the workspace for ELF objects.
+type soVirtuals struct {
+	Provides []string
+	Depends  []string
+}
+
+// scanELFVirtuals walks fsys looking for ELF shared objects and executables,
+// producing the apk-tools `so:`/`cmd:` provides and `so:` depends that
+// apk-tools' shared-library resolver expects. Sonames provided by the
+// package itself are not also emitted as depends.
+func (pc *PackageContext) scanELFVirtuals(fsys fs.FS) (soVirtuals, error) {
+	var v soVirtuals
+	providedSonames := map[string]bool{}
+	neededSonames := map[string]bool{}
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, ferr := fsys.Open(p)
+		if ferr != nil {
+			// Not every file is readable as a plain file (e.g. symlinks
+			// surfaced by some fs.FS implementations); skip rather than fail.
+			return nil //nolint:nilerr
+		}
+		defer f.Close()
+
+		ra, ok := f.(elfReaderAt)
+		if !ok {
+			return nil
+		}
+
+		ef, eerr := elf.NewFile(ra)
+		if eerr != nil {
+			// Not an ELF file.
+			return nil //nolint:nilerr
+		}
+		defer ef.Close()
+
+		if soname, ok := elfSoname(ef); ok {
+			providedSonames[soname] = true
+			v.Provides = append(v.Provides, fmt.Sprintf("so:%s=%s", soname, pc.Origin.Version))
+		}
+
+		for _, needed := range elfNeeded(ef) {
+			neededSonames[needed] = true
+		}
+
+		if dir := path.Dir(p); binDirs[dir] {
+			v.Provides = append(v.Provides, fmt.Sprintf("cmd:%s=%s-r%d", path.Base(p), pc.Origin.Version, pc.Origin.Epoch))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return soVirtuals{}, fmt.Errorf("unable to scan workspace for ELF virtuals: %w", err)
+	}
+
+	for soname := range neededSonames {
+		if providedSonames[soname] {
+			continue
+		}
+		v.Depends = append(v.Depends, fmt.Sprintf("so:%s", soname))
+	}
+
+	v = pc.applyVirtualOverrides(v)
+
+	sort.Strings(v.Provides)
+	sort.Strings(v.Depends)
+	return v, nil
+}
+
+// applyVirtualOverrides suppresses or pins so:/cmd: virtuals per the
+// package's Options, and folds in any explicitly pinned versions.
+func (pc *PackageContext) applyVirtualOverrides(v soVirtuals) soVirtuals {
+	opts := pc.Origin.Options
+
+	if opts.NoProvides {
+		v.Provides = nil
+	}
+	if opts.NoDepends {
+		v.Depends = nil
+	}
+	if opts.NoCommands {
+		filtered := v.Provides[:0]
+		for _, p := range v.Provides {
+			if !isCmdProvide(p) {
+				filtered = append(filtered, p)
+			}
+		}
+		v.Provides = filtered
+	}
+
+	for soname, version := range opts.PinnedVirtuals {
+		prefix := fmt.Sprintf("so:%s=", soname)
+		filtered := v.Provides[:0]
+		for _, p := range v.Provides {
+			if !strings.HasPrefix(p, prefix) {
+				filtered = append(filtered, p)
+			}
+		}
+		v.Provides = append(filtered, fmt.Sprintf("%s%s", prefix, version))
+	}
+
+	return v
+}
+
+func isCmdProvide(s string) bool {
+	return len(s) > 4 && s[:4] == "cmd:"
+}
+
+// elfReaderAt is the subset of fs.File that debug/elf.NewFile requires.
+type elfReaderAt interface {
+	fs.File
+	ReadAt(p []byte, off int64) (int, error)
+}
+
+// elfSoname returns the DT_SONAME dynamic tag, if the ELF object has one.
+func elfSoname(ef *elf.File) (string, bool) {
+	sonames, err := ef.DynString(elf.DT_SONAME)
+	if err != nil || len(sonames) == 0 {
+		return "", false
+	}
+	return sonames[0], true
+}
+
+// elfNeeded returns the DT_NEEDED dynamic tags of the ELF object.
+func elfNeeded(ef *elf.File) []string {
+	needed, err := ef.DynString(elf.DT_NEEDED)
+	if err != nil {
+		return nil
+	}
+	return needed
+}
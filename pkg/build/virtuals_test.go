@@ -0,0 +1,93 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestApplyVirtualOverridesPinnedReplacesAutoDetected(t *testing.T) {
+	pc := &PackageContext{
+		Origin: &Package{
+			Options: PackageOption{
+				PinnedVirtuals: map[string]string{"libfoo.so.1": "2.0"},
+			},
+		},
+	}
+
+	got := pc.applyVirtualOverrides(soVirtuals{
+		Provides: []string{"so:libfoo.so.1=1.0", "so:libbar.so.1=1.0"},
+	})
+
+	want := map[string]bool{"so:libfoo.so.1=2.0": true, "so:libbar.so.1=1.0": true}
+	if len(got.Provides) != len(want) {
+		t.Fatalf("Provides = %v, want entries matching %v", got.Provides, want)
+	}
+	for _, p := range got.Provides {
+		if !want[p] {
+			t.Errorf("unexpected provide %q; a pinned soname must replace its auto-detected entry, not duplicate it", p)
+		}
+	}
+}
+
+func TestApplyVirtualOverridesNoProvidesNoDepends(t *testing.T) {
+	pc := &PackageContext{
+		Origin: &Package{
+			Options: PackageOption{NoProvides: true, NoDepends: true},
+		},
+	}
+
+	got := pc.applyVirtualOverrides(soVirtuals{
+		Provides: []string{"so:libfoo.so.1=1.0"},
+		Depends:  []string{"so:libbar.so.1"},
+	})
+
+	if got.Provides != nil || got.Depends != nil {
+		t.Errorf("got %+v, want both Provides and Depends suppressed", got)
+	}
+}
+
+func TestApplyVirtualOverridesNoCommands(t *testing.T) {
+	pc := &PackageContext{
+		Origin: &Package{Options: PackageOption{NoCommands: true}},
+	}
+
+	got := pc.applyVirtualOverrides(soVirtuals{
+		Provides: []string{"so:libfoo.so.1=1.0", "cmd:foo=1.0-r0"},
+	})
+
+	if len(got.Provides) != 1 || got.Provides[0] != "so:libfoo.so.1=1.0" {
+		t.Errorf("Provides = %v, want cmd: entries stripped", got.Provides)
+	}
+}
+
+func TestScanELFVirtualsSkipsNonELFFiles(t *testing.T) {
+	pc := &PackageContext{Origin: &Package{Version: "1.0"}}
+
+	fsys := fstest.MapFS{
+		"usr/bin/not-elf": {Data: []byte("#!/bin/sh\necho hi\n"), Mode: os.ModePerm},
+		"etc/readme.txt":  {Data: []byte("hello")},
+	}
+
+	got, err := pc.scanELFVirtuals(fsys)
+	if err != nil {
+		t.Fatalf("scanELFVirtuals: %v", err)
+	}
+	if len(got.Provides) != 0 || len(got.Depends) != 0 {
+		t.Errorf("got %+v, want no virtuals inferred from non-ELF files", got)
+	}
+}
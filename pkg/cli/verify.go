@@ -0,0 +1,127 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"chainguard.dev/melange/pkg/build"
+	"github.com/spf13/cobra"
+)
+
+// Verify returns the `melange verify` subcommand.
+func Verify() *cobra.Command {
+	var reproducible bool
+
+	cmd := &cobra.Command{
+		Use:     "verify",
+		Short:   "Verify properties of a melange build",
+		Example: "melange verify --reproducible config.yaml",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !reproducible {
+				return fmt.Errorf("verify requires --reproducible (no other verification modes are implemented yet)")
+			}
+			return verifyReproducible(cmd.Context(), args[0])
+		},
+	}
+
+	cmd.Flags().BoolVar(&reproducible, "reproducible", false,
+		"rebuild the package twice into separate tempdirs and fail if the resulting apks differ")
+
+	return cmd
+}
+
+// verifyReproducible builds configFile twice, each into its own tempdir,
+// and fails if the resulting apk trees are not byte-identical. This is the
+// check Wolfi-style rebuild attestations rely on.
+func verifyReproducible(ctx context.Context, configFile string) error {
+	firstDir, err := buildToTempDir(ctx, configFile)
+	if err != nil {
+		return fmt.Errorf("first build: %w", err)
+	}
+	defer os.RemoveAll(firstDir)
+
+	secondDir, err := buildToTempDir(ctx, configFile)
+	if err != nil {
+		return fmt.Errorf("second build: %w", err)
+	}
+	defer os.RemoveAll(secondDir)
+
+	return diffOutputDirs(firstDir, secondDir)
+}
+
+// buildToTempDir runs a full melange build of configFile into a fresh
+// temporary output directory and returns its path.
+func buildToTempDir(ctx context.Context, configFile string) (string, error) {
+	outDir, err := os.MkdirTemp("", "melange-verify-*")
+	if err != nil {
+		return "", fmt.Errorf("unable to create tempdir: %w", err)
+	}
+
+	bc, err := build.New(ctx,
+		build.WithConfig(configFile, nil),
+		build.WithOutDir(outDir),
+	)
+	if err != nil {
+		os.RemoveAll(outDir)
+		return "", fmt.Errorf("unable to configure build: %w", err)
+	}
+
+	if err := bc.BuildPackage(); err != nil {
+		os.RemoveAll(outDir)
+		return "", fmt.Errorf("unable to build package: %w", err)
+	}
+
+	return outDir, nil
+}
+
+// diffOutputDirs walks want looking for every .apk it contains and compares
+// each byte-for-byte against its counterpart under got.
+func diffOutputDirs(want, got string) error {
+	return filepath.Walk(want, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".apk" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(want, path)
+		if err != nil {
+			return err
+		}
+
+		wantBytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		gotBytes, err := os.ReadFile(filepath.Join(got, rel))
+		if err != nil {
+			return fmt.Errorf("%s: missing from second build: %w", rel, err)
+		}
+
+		if !bytes.Equal(wantBytes, gotBytes) {
+			return fmt.Errorf("%s: not reproducible, apk bytes differ between builds", rel)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Chainguard, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeApk(t *testing.T, dir, rel string, contents []byte) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffOutputDirsIdentical(t *testing.T) {
+	want, got := t.TempDir(), t.TempDir()
+	writeApk(t, want, "x86_64/foo-1.0-r0.apk", []byte("same bytes"))
+	writeApk(t, got, "x86_64/foo-1.0-r0.apk", []byte("same bytes"))
+
+	if err := diffOutputDirs(want, got); err != nil {
+		t.Errorf("diffOutputDirs() = %v, want nil for byte-identical builds", err)
+	}
+}
+
+func TestDiffOutputDirsDiffers(t *testing.T) {
+	want, got := t.TempDir(), t.TempDir()
+	writeApk(t, want, "x86_64/foo-1.0-r0.apk", []byte("first build"))
+	writeApk(t, got, "x86_64/foo-1.0-r0.apk", []byte("second build"))
+
+	if err := diffOutputDirs(want, got); err == nil {
+		t.Error("diffOutputDirs() = nil, want an error for non-reproducible apk bytes")
+	}
+}
+
+func TestDiffOutputDirsMissingFromSecondBuild(t *testing.T) {
+	want, got := t.TempDir(), t.TempDir()
+	writeApk(t, want, "x86_64/foo-1.0-r0.apk", []byte("only in first build"))
+
+	if err := diffOutputDirs(want, got); err == nil {
+		t.Error("diffOutputDirs() = nil, want an error when the second build is missing an apk")
+	}
+}
+
+func TestDiffOutputDirsIgnoresNonApkFiles(t *testing.T) {
+	want, got := t.TempDir(), t.TempDir()
+	writeApk(t, want, "x86_64/APKINDEX.tar.gz", []byte("index, differs"))
+	writeApk(t, got, "x86_64/APKINDEX.tar.gz", []byte("index, differs too"))
+
+	if err := diffOutputDirs(want, got); err != nil {
+		t.Errorf("diffOutputDirs() = %v, want nil: only .apk files should be compared", err)
+	}
+}